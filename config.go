@@ -0,0 +1,151 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// SensorConfig describes one temperature sensor to poll: its contribution
+// weight for the "weighted" aggregation strategy, and which fan zone (the
+// byte sent as the Dell raw "0x30 0x30 0x02 <zone>" selector) it drives.
+// Zone 0xff is the broadcast zone, matching the previous hard-coded
+// behavior. WarnTemp/MaxTemp override the global WARNTEMP/MAXTEMP env vars
+// for the zone this sensor drives; leave at zero to use the global default.
+type SensorConfig struct {
+	Name     string  `yaml:"name"`
+	Weight   float64 `yaml:"weight"`
+	Zone     byte    `yaml:"zone"`
+	WarnTemp float64 `yaml:"warn_temp"`
+	MaxTemp  float64 `yaml:"max_temp"`
+}
+
+// Config is the full set of sensor/aggregation tunables. It is built from
+// env vars and, when CONFIG_FILE points at a YAML file, overridden by it.
+type Config struct {
+	Sensors []SensorConfig `yaml:"sensors"`
+	// Aggregation selects how multiple sensor readings within a zone are
+	// combined into the single temperature fed to that zone's controller,
+	// and how all sensors' readings are combined for the chassis-wide
+	// auto/manual mode decision: "max", "mean", or "weighted".
+	Aggregation string `yaml:"aggregation"`
+}
+
+// defaultConfig derives a Config from env vars: SENSORS (falling back to
+// the single SENSOR var for backwards compatibility) and AGGREGATION.
+func defaultConfig() Config {
+	names := strings.Split(getEnv("SENSORS", SENSOR), ",")
+	sensors := make([]SensorConfig, 0, len(names))
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		sensors = append(sensors, SensorConfig{Name: name, Weight: 1, Zone: 0xff})
+	}
+	return Config{
+		Sensors:     sensors,
+		Aggregation: getEnv("AGGREGATION", "max"),
+	}
+}
+
+// loadConfig builds the env-var-derived Config, then overrides it with
+// CONFIG_FILE (YAML) if that env var is set.
+func loadConfig() (Config, error) {
+	cfg := defaultConfig()
+
+	path := getEnv("CONFIG_FILE", "")
+	if path == "" {
+		return cfg, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cfg, fmt.Errorf("reading config file %s: %v", path, err)
+	}
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("parsing config file %s: %v", path, err)
+	}
+	return cfg, nil
+}
+
+// SensorReading pairs a polled temperature with the SensorConfig it came
+// from, so aggregation can apply weights and callers know which zone it
+// belongs to.
+type SensorReading struct {
+	SensorConfig
+	Temp float64
+}
+
+// aggregate combines readings according to strategy: one zone's readings for
+// that zone's controller, or all sensors' readings for the chassis-wide
+// auto/manual mode decision.
+func aggregate(strategy string, readings []SensorReading) float64 {
+	if len(readings) == 0 {
+		return 0
+	}
+	switch strategy {
+	case "mean":
+		var sum float64
+		for _, r := range readings {
+			sum += r.Temp
+		}
+		return sum / float64(len(readings))
+	case "weighted":
+		var sum, totalWeight float64
+		for _, r := range readings {
+			sum += r.Temp * r.Weight
+			totalWeight += r.Weight
+		}
+		if totalWeight == 0 {
+			return 0
+		}
+		return sum / totalWeight
+	default: // "max"
+		max := readings[0].Temp
+		for _, r := range readings[1:] {
+			if r.Temp > max {
+				max = r.Temp
+			}
+		}
+		return max
+	}
+}
+
+// groupByZone buckets readings by the fan zone they should drive.
+func groupByZone(readings []SensorReading) map[byte][]SensorReading {
+	byZone := make(map[byte][]SensorReading)
+	for _, r := range readings {
+		byZone[r.Zone] = append(byZone[r.Zone], r)
+	}
+	return byZone
+}
+
+// zoneThresholds derives the WarnTemp/MaxTemp pair to use for a zone's
+// controller: the lowest per-sensor override among the sensors feeding the
+// zone, falling back to the global default for any sensor that left its
+// override at zero. The zone controller uses warnTemp as its PID setpoint.
+// The auto/manual mode switch is made chassis-wide in main, from the
+// aggregate of all sensors against the global WARNTEMP/MAXTEMP; these
+// per-zone thresholds do not take part in it.
+func zoneThresholds(readings []SensorReading, globalWarnTemp, globalMaxTemp float64) (warnTemp, maxTemp float64) {
+	warnTemp, maxTemp = globalWarnTemp, globalMaxTemp
+	for i, r := range readings {
+		w, m := r.WarnTemp, r.MaxTemp
+		if w == 0 {
+			w = globalWarnTemp
+		}
+		if m == 0 {
+			m = globalMaxTemp
+		}
+		if i == 0 || w < warnTemp {
+			warnTemp = w
+		}
+		if i == 0 || m < maxTemp {
+			maxTemp = m
+		}
+	}
+	return warnTemp, maxTemp
+}
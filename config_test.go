@@ -0,0 +1,84 @@
+package main
+
+import "testing"
+
+func TestAggregateMax(t *testing.T) {
+	readings := []SensorReading{
+		{SensorConfig: SensorConfig{Name: "a"}, Temp: 20},
+		{SensorConfig: SensorConfig{Name: "b"}, Temp: 35},
+		{SensorConfig: SensorConfig{Name: "c"}, Temp: 28},
+	}
+	if got := aggregate("max", readings); got != 35 {
+		t.Errorf("aggregate(max) = %v, want 35", got)
+	}
+}
+
+func TestAggregateMean(t *testing.T) {
+	readings := []SensorReading{
+		{SensorConfig: SensorConfig{Name: "a"}, Temp: 20},
+		{SensorConfig: SensorConfig{Name: "b"}, Temp: 30},
+	}
+	if got := aggregate("mean", readings); got != 25 {
+		t.Errorf("aggregate(mean) = %v, want 25", got)
+	}
+}
+
+func TestAggregateWeighted(t *testing.T) {
+	readings := []SensorReading{
+		{SensorConfig: SensorConfig{Name: "a", Weight: 1}, Temp: 20},
+		{SensorConfig: SensorConfig{Name: "b", Weight: 3}, Temp: 30},
+	}
+	// (20*1 + 30*3) / (1+3) = 27.5
+	if got := aggregate("weighted", readings); got != 27.5 {
+		t.Errorf("aggregate(weighted) = %v, want 27.5", got)
+	}
+}
+
+func TestAggregateWeightedZeroTotalWeight(t *testing.T) {
+	readings := []SensorReading{
+		{SensorConfig: SensorConfig{Name: "a", Weight: 0}, Temp: 20},
+	}
+	if got := aggregate("weighted", readings); got != 0 {
+		t.Errorf("aggregate(weighted) with zero total weight = %v, want 0", got)
+	}
+}
+
+func TestAggregateEmptyReadings(t *testing.T) {
+	if got := aggregate("max", nil); got != 0 {
+		t.Errorf("aggregate with no readings = %v, want 0", got)
+	}
+}
+
+func TestAggregateUnknownStrategyFallsBackToMax(t *testing.T) {
+	readings := []SensorReading{
+		{SensorConfig: SensorConfig{Name: "a"}, Temp: 20},
+		{SensorConfig: SensorConfig{Name: "b"}, Temp: 40},
+	}
+	if got := aggregate("bogus", readings); got != 40 {
+		t.Errorf("aggregate(bogus) = %v, want fallback to max behavior (40)", got)
+	}
+}
+
+func TestZoneThresholdsFallsBackToGlobalDefaults(t *testing.T) {
+	readings := []SensorReading{
+		{SensorConfig: SensorConfig{Name: "a"}, Temp: 20},
+	}
+	warnTemp, maxTemp := zoneThresholds(readings, 27, 30)
+	if warnTemp != 27 || maxTemp != 30 {
+		t.Errorf("zoneThresholds with no overrides = (%v, %v), want (27, 30)", warnTemp, maxTemp)
+	}
+}
+
+func TestZoneThresholdsUsesLowestPerSensorOverride(t *testing.T) {
+	readings := []SensorReading{
+		{SensorConfig: SensorConfig{Name: "a", WarnTemp: 25, MaxTemp: 28}, Temp: 20},
+		{SensorConfig: SensorConfig{Name: "b", WarnTemp: 22, MaxTemp: 32}, Temp: 21},
+	}
+	warnTemp, maxTemp := zoneThresholds(readings, 27, 30)
+	if warnTemp != 22 {
+		t.Errorf("zoneThresholds WarnTemp = %v, want the lowest override 22", warnTemp)
+	}
+	if maxTemp != 28 {
+		t.Errorf("zoneThresholds MaxTemp = %v, want the lowest override 28", maxTemp)
+	}
+}
@@ -0,0 +1,95 @@
+// Package controller turns a stream of temperature samples into a fan
+// setpoint without the oscillation that comes from flipping between two
+// hard-coded RPM buckets every poll.
+package controller
+
+// Config holds the PID gains and hysteresis band used to derive a fan
+// setpoint from a temperature sample.
+type Config struct {
+	KP, KI, KD float64
+	// Setpoint is the target temperature the PID loop tries to hold.
+	Setpoint float64
+	// MinOutput/MaxOutput clamp the PID output to the usable FANSPEEDS hex
+	// range (e.g. 0x04..0x07).
+	MinOutput, MaxOutput float64
+	// WarnTemp/MaxTemp and HysteresisBand drive the auto/manual mode
+	// switch: mode only flips once temp crosses warn/max by more than the
+	// band, instead of on every poll that straddles the raw threshold.
+	WarnTemp, MaxTemp float64
+	HysteresisBand    float64
+}
+
+// Mode is the fan mode selected by the hysteresis band.
+type Mode int
+
+const (
+	ModeManual Mode = iota
+	ModeAuto
+)
+
+func (m Mode) String() string {
+	if m == ModeAuto {
+		return "auto"
+	}
+	return "manual"
+}
+
+// Controller is a PID loop with clamped output plus a hysteresis band that
+// debounces the auto/manual mode transition.
+type Controller struct {
+	cfg Config
+
+	integral float64
+	prevErr  float64
+	mode     Mode
+}
+
+// New builds a Controller, starting in manual mode.
+func New(cfg Config) *Controller {
+	return &Controller{cfg: cfg, mode: ModeManual}
+}
+
+// Update feeds a new temperature sample (°C) and the elapsed time since the
+// previous sample, and returns the fan setpoint (clamped to
+// MinOutput..MaxOutput) to drive towards Setpoint.
+func (c *Controller) Update(temp, dt float64) float64 {
+	err := temp - c.cfg.Setpoint
+
+	c.integral += err * dt
+	var derivative float64
+	if dt > 0 {
+		derivative = (err - c.prevErr) / dt
+	}
+	c.prevErr = err
+
+	output := c.cfg.KP*err + c.cfg.KI*c.integral + c.cfg.KD*derivative
+
+	if output < c.cfg.MinOutput {
+		output = c.cfg.MinOutput
+		// anti-windup: don't keep accumulating integral while clamped.
+		c.integral -= err * dt
+	} else if output > c.cfg.MaxOutput {
+		output = c.cfg.MaxOutput
+		c.integral -= err * dt
+	}
+
+	return output
+}
+
+// Mode applies the hysteresis band around WarnTemp/MaxTemp to decide
+// whether the chassis should be in auto (BMC-controlled) or manual
+// (PID-controlled) fan mode, only flipping once temp has crossed a
+// threshold by more than HysteresisBand.
+func (c *Controller) Mode(temp float64) Mode {
+	switch c.mode {
+	case ModeAuto:
+		if temp < c.cfg.WarnTemp-c.cfg.HysteresisBand {
+			c.mode = ModeManual
+		}
+	default:
+		if temp > c.cfg.MaxTemp+c.cfg.HysteresisBand {
+			c.mode = ModeAuto
+		}
+	}
+	return c.mode
+}
@@ -0,0 +1,66 @@
+package controller
+
+import "testing"
+
+func TestUpdateClampsToOutputRange(t *testing.T) {
+	c := New(Config{KP: 2, Setpoint: 30, MinOutput: 4, MaxOutput: 7})
+
+	if got := c.Update(50, 1); got != 7 {
+		t.Errorf("Update(50, 1) = %v, want clamped to MaxOutput 7", got)
+	}
+}
+
+func TestUpdateAntiWindupStopsIntegralGrowthWhileClamped(t *testing.T) {
+	c := New(Config{KP: 0, KI: 1, Setpoint: 30, MinOutput: 0, MaxOutput: 5})
+
+	c.Update(50, 1)
+	integralAfterFirstClamp := c.integral
+	c.Update(50, 1)
+
+	if c.integral != integralAfterFirstClamp {
+		t.Errorf("integral grew from %v to %v while output stayed clamped, anti-windup should hold it steady", integralAfterFirstClamp, c.integral)
+	}
+}
+
+func TestUpdateTracksSetpointWithoutClamping(t *testing.T) {
+	c := New(Config{KP: 1, Setpoint: 30, MinOutput: 0, MaxOutput: 10})
+
+	got := c.Update(31, 1)
+	if got != 1 {
+		t.Errorf("Update(31, 1) = %v, want proportional term 1", got)
+	}
+}
+
+func TestModeStaysAutoWithinHysteresisBand(t *testing.T) {
+	c := New(Config{WarnTemp: 27, MaxTemp: 30, HysteresisBand: 2})
+	c.mode = ModeAuto
+
+	if got := c.Mode(26); got != ModeAuto {
+		t.Errorf("Mode(26) = %v, want ModeAuto to hold until temp drops below WarnTemp-band", got)
+	}
+}
+
+func TestModeFallsBackToManualBelowWarnTempMinusBand(t *testing.T) {
+	c := New(Config{WarnTemp: 27, MaxTemp: 30, HysteresisBand: 2})
+	c.mode = ModeAuto
+
+	if got := c.Mode(24); got != ModeManual {
+		t.Errorf("Mode(24) = %v, want ModeManual once temp drops below WarnTemp-band", got)
+	}
+}
+
+func TestModeEscalatesToAutoAboveMaxTempPlusBand(t *testing.T) {
+	c := New(Config{WarnTemp: 27, MaxTemp: 30, HysteresisBand: 2})
+
+	if got := c.Mode(33); got != ModeAuto {
+		t.Errorf("Mode(33) = %v, want ModeAuto once temp exceeds MaxTemp+band", got)
+	}
+}
+
+func TestModeStaysManualWithinHysteresisBand(t *testing.T) {
+	c := New(Config{WarnTemp: 27, MaxTemp: 30, HysteresisBand: 2})
+
+	if got := c.Mode(31); got != ModeManual {
+		t.Errorf("Mode(31) = %v, want ModeManual to hold until temp exceeds MaxTemp+band", got)
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/coreos/go-systemd/v22/daemon"
+)
+
+// newShutdownContext returns a context canceled on SIGINT/SIGTERM. Before
+// canceling it notifies systemd (Type=notify units) that we're stopping, so
+// `systemctl stop` doesn't have to wait out the full TimeoutStopSec.
+func newShutdownContext() context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	stopChan := make(chan os.Signal, 1)
+	signal.Notify(stopChan, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-stopChan
+		Logger.Info().Msg("Shutting down gracefully")
+		if _, err := daemon.SdNotify(false, daemon.SdNotifyStopping); err != nil {
+			Logger.Warn().Err(err).Msg("sd_notify STOPPING failed")
+		}
+		cancel()
+	}()
+	return ctx
+}
+
+// notifyReady tells systemd (Type=notify units) that startup finished.
+func notifyReady() {
+	if _, err := daemon.SdNotify(false, daemon.SdNotifyReady); err != nil {
+		Logger.Warn().Err(err).Msg("sd_notify READY failed")
+	}
+}
+
+// sleepOrDone sleeps for d, returning early (with true) if ctx is canceled
+// first.
+func sleepOrDone(ctx context.Context, d time.Duration) bool {
+	select {
+	case <-ctx.Done():
+		return true
+	case <-time.After(d):
+		return false
+	}
+}
+
+// watchdogPulse is beaten once per completed poll iteration. As long as
+// it's beaten at least as often as WATCHDOG_USEC, runWatchdog keeps
+// systemd's watchdog fed; if the poll loop wedges, the pulse goes stale and
+// the pings stop, so systemd restarts us per WatchdogSec=.
+type watchdogPulse struct {
+	lastBeat atomic.Int64
+}
+
+func newWatchdogPulse() *watchdogPulse {
+	w := &watchdogPulse{}
+	w.beat()
+	return w
+}
+
+func (w *watchdogPulse) beat() {
+	w.lastBeat.Store(time.Now().UnixNano())
+}
+
+// runWatchdog is a no-op unless WATCHDOG_USEC is set (i.e. WatchdogSec= is
+// configured on the unit), in which case it pings systemd at half that
+// interval for as long as pulse keeps getting beaten.
+func runWatchdog(ctx context.Context, pulse *watchdogPulse) {
+	interval, err := daemon.SdWatchdogEnabled(false)
+	if err != nil || interval == 0 {
+		return
+	}
+	ticker := time.NewTicker(interval / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if time.Since(time.Unix(0, pulse.lastBeat.Load())) > interval {
+				Logger.Warn().Dur("stall", time.Since(time.Unix(0, pulse.lastBeat.Load()))).Msg("Poll loop has not made progress, withholding watchdog ping")
+				continue
+			}
+			if _, err := daemon.SdNotify(false, daemon.SdNotifyWatchdog); err != nil {
+				Logger.Warn().Err(err).Msg("sd_notify WATCHDOG failed")
+			}
+		}
+	}
+}
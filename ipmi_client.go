@@ -0,0 +1,36 @@
+package main
+
+import "fmt"
+
+// IPMIClient abstracts the transport used to talk to the BMC so the rest of
+// the program does not care whether readings/commands travel over a shelled
+// out ipmitool invocation or a native RMCP+ session.
+type IPMIClient interface {
+	// GetSensorReading returns the current reading for the sensor selected
+	// by selector (a sensor name, or a numeric sensor ID if selector parses
+	// as one) along with its unit (e.g. "degrees C").
+	GetSensorReading(selector string) (float64, string, error)
+	// RawCommand issues a raw IPMI request (netfn/cmd plus payload) and
+	// returns the response data, with completion-code handling already
+	// applied.
+	RawCommand(netfn, cmd byte, data []byte) ([]byte, error)
+	Close() error
+}
+
+// newIPMIClient builds the configured backend. BACKEND defaults to
+// "ipmitool" so existing deployments keep working unchanged; set
+// BACKEND=native to use the persistent RMCP+ session instead, or
+// BACKEND=freeipmi for hosts that have FreeIPMI's ipmi-sensors/ipmi-raw
+// installed instead of ipmitool.
+func newIPMIClient() (IPMIClient, error) {
+	switch backend := getEnv("BACKEND", "ipmitool"); backend {
+	case "ipmitool":
+		return newShellIPMIClient(IPMIHOST, IPMIUSER, IPMIPW), nil
+	case "native":
+		return newNativeIPMIClient(IPMIHOST, IPMIUSER, IPMIPW)
+	case "freeipmi":
+		return newFreeipmiClient(IPMIHOST, IPMIUSER, IPMIPW), nil
+	default:
+		return nil, fmt.Errorf("unknown BACKEND %q (want ipmitool, native, or freeipmi)", backend)
+	}
+}
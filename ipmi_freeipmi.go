@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// freeipmiClient is an alternate shell-out backend for hosts that have
+// FreeIPMI's ipmi-sensors/ipmi-raw installed instead of ipmitool, matching
+// the dual-backend pattern cc-metric-collector uses for its IPMI collector.
+type freeipmiClient struct {
+	host, user, pass string
+}
+
+func newFreeipmiClient(host, user, pass string) *freeipmiClient {
+	return &freeipmiClient{host: host, user: user, pass: pass}
+}
+
+func (c *freeipmiClient) driverArgs(extra ...string) []string {
+	return append([]string{"-D", "LAN_2_0", "-h", c.host, "-u", c.user, "-p", c.pass}, extra...)
+}
+
+func (c *freeipmiClient) run(name string, args []string) (string, error) {
+	cmd := exec.Command(name, args...)
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	if err := cmd.Run(); err != nil {
+		Logger.Error().Str("cmd", name).Strs("args", args).Err(err).Str("output", out.String()).Msg("Error running FreeIPMI command")
+		return "", fmt.Errorf("running %s %v: %v", name, args, err)
+	}
+	return out.String(), nil
+}
+
+// GetSensorReading shells out to "ipmi-sensors --comma-separated-output
+// --no-header-output" and parses the ID,Name,Type,Reading,Units,Event
+// columns, selecting by sensor ID if selector parses as a number, by name
+// otherwise.
+func (c *freeipmiClient) GetSensorReading(selector string) (float64, string, error) {
+	output, err := c.run("ipmi-sensors", c.driverArgs("--comma-separated-output", "--no-header-output"))
+	if err != nil {
+		return 0, "", fmt.Errorf("error running ipmi-sensors: %v", err)
+	}
+
+	reader := csv.NewReader(strings.NewReader(output))
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		return 0, "", fmt.Errorf("error parsing ipmi-sensors CSV output: %v", err)
+	}
+
+	wantID, byID := parseSensorID(selector)
+	for _, row := range records {
+		if len(row) < 5 {
+			continue
+		}
+		if byID {
+			id, err := strconv.ParseInt(strings.TrimSpace(row[0]), 10, 64)
+			if err != nil || id != wantID {
+				continue
+			}
+		} else if !strings.EqualFold(strings.TrimSpace(row[1]), selector) {
+			continue
+		}
+
+		temp, err := strconv.ParseFloat(strings.TrimSpace(row[3]), 64)
+		if err != nil {
+			return 0, "", fmt.Errorf("error parsing temperature for sensor %q: %v", selector, err)
+		}
+		return temp, strings.TrimSpace(row[4]), nil
+	}
+	return 0, "", fmt.Errorf("sensor %q not found in ipmi-sensors output", selector)
+}
+
+// RawCommand shells out to FreeIPMI's ipmi-raw tool.
+func (c *freeipmiClient) RawCommand(netfn, cmd byte, data []byte) ([]byte, error) {
+	args := []string{fmt.Sprintf("0x%02x", netfn), fmt.Sprintf("0x%02x", cmd)}
+	for _, b := range data {
+		args = append(args, fmt.Sprintf("0x%02x", b))
+	}
+	output, err := c.run("ipmi-raw", c.driverArgs(args...))
+	if err != nil {
+		return nil, err
+	}
+	var resp []byte
+	for _, field := range strings.Fields(output) {
+		var b int64
+		if _, err := fmt.Sscanf(field, "%x", &b); err == nil {
+			resp = append(resp, byte(b))
+		}
+	}
+	return resp, nil
+}
+
+func (c *freeipmiClient) Close() error {
+	return nil
+}
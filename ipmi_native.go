@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	goipmi "github.com/bougou/go-ipmi"
+)
+
+// nativeIPMIClient keeps a single authenticated RMCP+ (IPMI v2.0) session
+// open for the lifetime of the process instead of forking ipmitool on every
+// poll. It transparently reconnects if the session drops, and keeps the
+// session alive between polls with a periodic Get Device ID request.
+type nativeIPMIClient struct {
+	host, user, pass string
+
+	mu      sync.Mutex
+	client  *goipmi.Client
+	closeCh chan struct{}
+}
+
+func newNativeIPMIClient(host, user, pass string) (*nativeIPMIClient, error) {
+	c := &nativeIPMIClient{host: host, user: user, pass: pass, closeCh: make(chan struct{})}
+	if err := c.connect(); err != nil {
+		return nil, err
+	}
+	go c.keepalive(30 * time.Second)
+	return c, nil
+}
+
+func (c *nativeIPMIClient) connect() error {
+	client, err := goipmi.NewClient(c.host, 623, c.user, c.pass)
+	if err != nil {
+		return fmt.Errorf("creating native IPMI client: %v", err)
+	}
+	client.WithInterface(goipmi.InterfaceLanplus)
+	if err := client.Connect(context.Background()); err != nil {
+		return fmt.Errorf("opening RMCP+ session to %s: %v", c.host, err)
+	}
+	c.client = client
+	Logger.Info().Str("host", c.host).Msg("Native IPMI session established")
+	return nil
+}
+
+// withSession runs fn against the live session, reconnecting once on
+// failure in case the BMC dropped us (idle timeout, reset, etc.).
+func (c *nativeIPMIClient) withSession(fn func(*goipmi.Client) error) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := fn(c.client); err == nil {
+		return nil
+	} else {
+		Logger.Warn().Err(err).Msg("Native IPMI session error, reconnecting")
+	}
+
+	if err := c.connect(); err != nil {
+		return err
+	}
+	return fn(c.client)
+}
+
+// GetSensorReading selects the sensor by name or, if selector parses as a
+// number, by sensor ID, matching the shell and FreeIPMI backends.
+func (c *nativeIPMIClient) GetSensorReading(selector string) (float64, string, error) {
+	var temp float64
+	var unit string
+	err := c.withSession(func(client *goipmi.Client) error {
+		var sensor *goipmi.Sensor
+		var err error
+		if id, ok := parseSensorID(selector); ok {
+			sensor, err = client.GetSensorByID(context.Background(), uint8(id))
+		} else {
+			sensor, err = client.GetSensorByName(context.Background(), selector)
+		}
+		if err != nil {
+			return err
+		}
+		temp = sensor.Value
+		unit = sensor.SensorUnit.String()
+		return nil
+	})
+	if err != nil {
+		return 0, "", fmt.Errorf("native sensor reading for %q: %v", selector, err)
+	}
+	return temp, unit, nil
+}
+
+// RawCommand issues a raw request and folds the "0xcc invalid data field"
+// completion code into a non-error, matching the shell backend's behavior
+// for fan-control commands the BMC routinely nacks harmlessly.
+// CommandRawResponse carries no completion-code field of its own, so the
+// 0xCC case is detected from the error go-ipmi returns for it instead.
+func (c *nativeIPMIClient) RawCommand(netfn, cmd byte, data []byte) ([]byte, error) {
+	var resp []byte
+	err := c.withSession(func(client *goipmi.Client) error {
+		out, err := client.RawCommand(context.Background(), goipmi.NetFn(netfn), uint8(cmd), data, "")
+		if err != nil {
+			if strings.Contains(err.Error(), "0xcc") {
+				Logger.Warn().Str("netfn", fmt.Sprintf("0x%02x", netfn)).Str("cmd", fmt.Sprintf("0x%02x", cmd)).Msg("Raw command completed with 0xCC (invalid data field), continuing")
+				resp = nil
+				return nil
+			}
+			return err
+		}
+		resp = out.Response
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("native raw command netfn=0x%02x cmd=0x%02x: %v", netfn, cmd, err)
+	}
+	return resp, nil
+}
+
+func (c *nativeIPMIClient) Close() error {
+	close(c.closeCh)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.client == nil {
+		return nil
+	}
+	return c.client.Close(context.Background())
+}
+
+// keepalive periodically issues a lightweight Get Device ID request so idle
+// BMCs do not close the session out from under us between polls. It runs
+// for the client's lifetime, started from newNativeIPMIClient.
+func (c *nativeIPMIClient) keepalive(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.closeCh:
+			return
+		case <-ticker.C:
+			if err := c.withSession(func(client *goipmi.Client) error {
+				_, err := client.GetDeviceID(context.Background())
+				return err
+			}); err != nil {
+				Logger.Warn().Err(err).Msg("Native IPMI keepalive failed")
+			}
+		}
+	}
+}
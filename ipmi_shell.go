@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// shellIPMIClient is the original ipmitool-shell-out backend, kept as the
+// default/fallback so hosts without the native library still work.
+type shellIPMIClient struct {
+	host, user, pass string
+}
+
+func newShellIPMIClient(host, user, pass string) *shellIPMIClient {
+	return &shellIPMIClient{host: host, user: user, pass: pass}
+}
+
+func (c *shellIPMIClient) args(extra ...string) []string {
+	return append([]string{"-I", "lanplus", "-H", c.host, "-U", c.user, "-P", c.pass}, extra...)
+}
+
+// run executes an ipmitool invocation with the given sub-arguments.
+func (c *shellIPMIClient) run(args []string) (string, error) {
+	cmd := exec.Command("ipmitool", c.args(args...)...)
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	err := cmd.Run()
+	if err != nil {
+		if strings.Contains(out.String(), "rsp=0xcc") && strings.Contains(out.String(), "Invalid data field in request") {
+			Logger.Warn().Strs("args", args).Msg("IPMI command executed with a minor error (rsp=0xcc), continuing")
+			return out.String(), nil
+		}
+		Logger.Error().Strs("args", args).Err(err).Str("output", out.String()).Msg("Error running IPMI command")
+		return "", fmt.Errorf("critical error running IPMI command %v: %v", args, err)
+	}
+	Logger.Debug().Strs("args", args).Str("output", out.String()).Msg("IPMI command successful")
+	return out.String(), nil
+}
+
+// GetSensorReading selects the sensor by name or, if selector parses as a
+// number, by sensor ID, and reads it via "-c sdr list" so fractional
+// readings (22.500) and non-English locales don't trip up whitespace-based
+// parsing the way "sdr get" did.
+func (c *shellIPMIClient) GetSensorReading(selector string) (float64, string, error) {
+	output, err := c.run([]string{"-c", "sdr", "list"})
+	if err != nil {
+		return 0, "", fmt.Errorf("error running IPMI temperature command: %v", err)
+	}
+
+	reader := csv.NewReader(strings.NewReader(output))
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		return 0, "", fmt.Errorf("error parsing CSV sdr output: %v", err)
+	}
+
+	wantID, byID := parseSensorID(selector)
+	for _, row := range records {
+		if len(row) < 2 {
+			continue
+		}
+		if byID {
+			id, err := parseSensorHexID(row[1])
+			if err != nil || id != wantID {
+				continue
+			}
+		} else if !strings.EqualFold(strings.TrimSpace(row[0]), selector) {
+			continue
+		}
+
+		fields := strings.Fields(row[len(row)-1])
+		if len(fields) == 0 {
+			return 0, "", fmt.Errorf("empty reading for sensor %q: row %v", selector, row)
+		}
+		temp, err := strconv.ParseFloat(fields[0], 64)
+		if err != nil {
+			return 0, "", fmt.Errorf("error parsing temperature for sensor %q: %v", selector, err)
+		}
+		unit := "degrees C"
+		if len(fields) > 1 {
+			unit = strings.Join(fields[1:], " ")
+		}
+		return temp, unit, nil
+	}
+	return 0, "", fmt.Errorf("sensor %q not found in CSV sdr output", selector)
+}
+
+// parseSensorID reports whether selector names a sensor by numeric ID
+// (decimal or 0x-prefixed hex) rather than by name.
+func parseSensorID(selector string) (int64, bool) {
+	id, err := strconv.ParseInt(selector, 0, 64)
+	if err != nil {
+		return 0, false
+	}
+	return id, true
+}
+
+// parseSensorHexID parses ipmitool's sensor-number column, e.g. "01h" or
+// "0x01".
+func parseSensorHexID(field string) (int64, error) {
+	field = strings.TrimSpace(field)
+	field = strings.TrimSuffix(strings.TrimSuffix(field, "h"), "H")
+	field = strings.TrimPrefix(field, "0x")
+	return strconv.ParseInt(field, 16, 64)
+}
+
+func (c *shellIPMIClient) RawCommand(netfn, cmd byte, data []byte) ([]byte, error) {
+	args := []string{"raw", fmt.Sprintf("0x%02x", netfn), fmt.Sprintf("0x%02x", cmd)}
+	for _, b := range data {
+		args = append(args, fmt.Sprintf("0x%02x", b))
+	}
+	output, err := c.run(args)
+	if err != nil {
+		return nil, err
+	}
+	var resp []byte
+	for _, field := range strings.Fields(output) {
+		var b int64
+		if _, err := fmt.Sscanf(field, "%x", &b); err == nil {
+			resp = append(resp, byte(b))
+		}
+	}
+	return resp, nil
+}
+
+func (c *shellIPMIClient) Close() error {
+	return nil
+}
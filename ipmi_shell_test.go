@@ -0,0 +1,50 @@
+package main
+
+import "testing"
+
+func TestParseSensorIDNumeric(t *testing.T) {
+	id, ok := parseSensorID("12")
+	if !ok || id != 12 {
+		t.Errorf("parseSensorID(12) = (%v, %v), want (12, true)", id, ok)
+	}
+}
+
+func TestParseSensorIDHexPrefixed(t *testing.T) {
+	id, ok := parseSensorID("0x1a")
+	if !ok || id != 0x1a {
+		t.Errorf("parseSensorID(0x1a) = (%v, %v), want (26, true)", id, ok)
+	}
+}
+
+func TestParseSensorIDName(t *testing.T) {
+	if _, ok := parseSensorID("Ambient Temp"); ok {
+		t.Error("parseSensorID(\"Ambient Temp\") reported true, want false for a non-numeric name")
+	}
+}
+
+func TestParseSensorHexIDWithHSuffix(t *testing.T) {
+	id, err := parseSensorHexID("01h")
+	if err != nil || id != 1 {
+		t.Errorf("parseSensorHexID(01h) = (%v, %v), want (1, nil)", id, err)
+	}
+}
+
+func TestParseSensorHexIDWithUppercaseHSuffix(t *testing.T) {
+	id, err := parseSensorHexID("1AH")
+	if err != nil || id != 0x1a {
+		t.Errorf("parseSensorHexID(1AH) = (%v, %v), want (26, nil)", id, err)
+	}
+}
+
+func TestParseSensorHexIDWith0xPrefix(t *testing.T) {
+	id, err := parseSensorHexID("0x1a")
+	if err != nil || id != 0x1a {
+		t.Errorf("parseSensorHexID(0x1a) = (%v, %v), want (26, nil)", id, err)
+	}
+}
+
+func TestParseSensorHexIDInvalid(t *testing.T) {
+	if _, err := parseSensorHexID("zz"); err == nil {
+		t.Error("parseSensorHexID(zz) returned nil error, want an error for non-hex input")
+	}
+}
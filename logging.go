@@ -0,0 +1,41 @@
+package main
+
+import (
+	"io"
+	"os"
+	"time"
+
+	"github.com/rs/zerolog"
+	lumberjack "gopkg.in/natefinch/lumberjack.v2"
+)
+
+// LOGFORMAT selects "json" (the default, machine-parseable for Loki/ELK) or
+// "text" (human-readable console) output. LOGMAXSIZEMB/LOGMAXBACKUPS/
+// LOGMAXDAYS configure lumberjack's size/backup-count/age-based rotation of
+// LOGFILE so it no longer grows without bound.
+var (
+	LOGFORMAT     = getEnv("LOG_FORMAT", "json")
+	LOGMAXSIZEMB  = getEnvInt("LOG_MAX_SIZE_MB", 100)
+	LOGMAXBACKUPS = getEnvInt("LOG_MAX_BACKUPS", 5)
+	LOGMAXDAYS    = getEnvInt("LOG_MAX_DAYS", 28)
+)
+
+// Logger is the structured logger used throughout the program in place of
+// the stdlib log package.
+var Logger zerolog.Logger
+
+func setupLogging() {
+	rotator := &lumberjack.Logger{
+		Filename:   LOGFILE,
+		MaxSize:    LOGMAXSIZEMB,
+		MaxBackups: LOGMAXBACKUPS,
+		MaxAge:     LOGMAXDAYS,
+	}
+
+	var out io.Writer = io.MultiWriter(os.Stdout, rotator)
+	if LOGFORMAT == "text" {
+		out = io.MultiWriter(zerolog.ConsoleWriter{Out: os.Stdout, TimeFormat: time.RFC3339}, rotator)
+	}
+
+	Logger = zerolog.New(out).With().Timestamp().Logger()
+}
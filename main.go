@@ -1,17 +1,12 @@
 package main
 
 import (
-	"bytes"
 	"fmt"
-	"io"
-	"log"
 	"os"
-	"os/exec"
-	"os/signal"
 	"strconv"
-	"strings"
-	"syscall"
 	"time"
+
+	"github.com/ItsMeSudo/11th-Gen-Dell-ShutUp/controller"
 )
 
 var (
@@ -29,124 +24,179 @@ var (
 		1560: "0x04", // Example: 1560 RPM
 	}
 	LOGFILE = getEnv("LOGFILE", "/var/log/ipmi-temp-monitor.log")
+
+	KP            = getEnvFloat("KP", 2.0)
+	KI            = getEnvFloat("KI", 0.1)
+	KD            = getEnvFloat("KD", 0.05)
+	HYSTERESIS    = getEnvFloat("HYSTERESIS", 2.0)
+	MAXCMDSPERMIN = getEnvInt("MAXCMDSPERMIN", 10)
 )
 
 func main() {
 	setupLogging()
 	defer recoverFromPanic()
 
-	handleShutdown()
+	ctx := newShutdownContext()
+	startMetricsServer()
+
+	cfg, err := loadConfig()
+	if err != nil {
+		Logger.Fatal().Err(err).Msg("Failed to load config")
+	}
 
-	for {
-		temp, err := getTemperature()
-		if err != nil {
-			log.Printf("Error getting temperature: %v", err)
-			time.Sleep(time.Duration(POLLINTERVAL) * time.Second)
+	client, err := newIPMIClient()
+	if err != nil {
+		Logger.Fatal().Err(err).Msg("Failed to initialize IPMI client")
+	}
+	defer client.Close()
+
+	// newZoneController builds a Controller for a zone, using that zone's
+	// sensors' WarnTemp/MaxTemp overrides in place of the global
+	// WARNTEMP/MAXTEMP where the config sets them (see zoneThresholds).
+	newZoneController := func(warnTemp, maxTemp float64) *controller.Controller {
+		return controller.New(controller.Config{
+			KP:             KP,
+			KI:             KI,
+			KD:             KD,
+			Setpoint:       warnTemp,
+			MinOutput:      float64(fanSpeedByte(FANSPEEDS[1560])),
+			MaxOutput:      float64(fanSpeedByte(FANSPEEDS[1920])),
+			WarnTemp:       warnTemp,
+			MaxTemp:        maxTemp,
+			HysteresisBand: HYSTERESIS,
+		})
+	}
+	overallCtrl := newZoneController(float64(WARNTEMP), float64(MAXTEMP))
+	zoneControllers := make(map[byte]*controller.Controller)
+	zoneLastSetpoint := make(map[byte]byte)
+
+	minCommandInterval := time.Minute / time.Duration(MAXCMDSPERMIN)
+	var lastCommandTime time.Time
+	lastSampleTime := time.Now()
+
+	pulse := newWatchdogPulse()
+	go runWatchdog(ctx, pulse)
+	notifyReady()
+
+	for ctx.Err() == nil {
+		readings := make([]SensorReading, 0, len(cfg.Sensors))
+		for _, sc := range cfg.Sensors {
+			start := time.Now()
+			temp, _, err := client.GetSensorReading(sc.Name)
+			observeCommand(start, err)
+			if err != nil {
+				Logger.Error().Str("sensor", sc.Name).Err(err).Msg("Error getting temperature")
+				continue
+			}
+			Logger.Info().Str("sensor", sc.Name).Float64("temp_c", temp).Msg("Sampled temperature")
+			sensorTemperature.WithLabelValues(sc.Name).Set(temp)
+			readings = append(readings, SensorReading{SensorConfig: sc, Temp: temp})
+		}
+		if len(readings) == 0 {
+			Logger.Warn().Msg("No sensor readings succeeded this poll, skipping")
+			if sleepOrDone(ctx, time.Duration(POLLINTERVAL)*time.Second) {
+				break
+			}
 			continue
 		}
 
-		log.Printf("Current temperature (%s): %d°C", SENSOR, temp)
-
-		if temp > MAXTEMP {
-			log.Printf("Temperature is BAD (%d°C). Setting fans to auto.", temp)
-			runIPMICommandWithRetry([]string{"raw", "0x30", "0x30", "0x01", "0x01"}, 3, 2*time.Second)
-		} else if temp > WARNTEMP {
-			log.Printf("Temperature is WARN (%d°C). Setting fans to manual mode.", temp)
-			runIPMICommandWithRetry([]string{"raw", "0x30", "0x30", "0x01", "0x00"}, 3, 2*time.Second)
-			runIPMICommandWithRetry([]string{"raw", "0x30", "0x30", "0x02", "0xff", FANSPEEDS[1920]}, 3, 2*time.Second)
-		} else {
-			log.Printf("Temperature is OK (%d°C). Setting fans to lower speed.", temp)
-			runIPMICommandWithRetry([]string{"raw", "0x30", "0x30", "0x01", "0x00"}, 3, 2*time.Second)
-			runIPMICommandWithRetry([]string{"raw", "0x30", "0x30", "0x02", "0xff", FANSPEEDS[1560]}, 3, 2*time.Second)
-		}
+		now := time.Now()
+		dt := now.Sub(lastSampleTime).Seconds()
+		lastSampleTime = now
 
-		time.Sleep(time.Duration(POLLINTERVAL) * time.Second)
-	}
-}
+		overallTemp := aggregate(cfg.Aggregation, readings)
+		Logger.Info().Str("strategy", cfg.Aggregation).Int("sensors", len(readings)).Float64("temp_c", overallTemp).Msg("Aggregated temperature")
 
-func setupLogging() {
-	logFile, err := os.OpenFile(LOGFILE, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-	if err != nil {
-		log.Fatalf("Failed to open log file: %v", err)
-	}
-	multiWriter := io.MultiWriter(os.Stdout, logFile)
-	log.SetOutput(multiWriter)
-	log.SetFlags(log.LstdFlags | log.Lshortfile)
-}
+		mode := overallCtrl.Mode(overallTemp)
+		setFanMode(mode.String())
 
-// recovers from panic and restarts the main loop.
-func recoverFromPanic() {
-	if r := recover(); r != nil {
-		log.Printf("Program crashed with error: %v. Restarting...", r)
-		main() // Restart main loop after crash
-	}
-}
+		rateLimited := time.Since(lastCommandTime) < minCommandInterval
 
-// gracefully shuts down the program on interrupt signals.
-func handleShutdown() {
-	stopChan := make(chan os.Signal, 1)
-	signal.Notify(stopChan, os.Interrupt, syscall.SIGTERM)
-	go func() {
-		<-stopChan
-		log.Println("Shutting down gracefully...")
-		os.Exit(0)
-	}()
-}
+		switch mode {
+		case controller.ModeAuto:
+			Logger.Info().Float64("temp_c", overallTemp).Str("mode", mode.String()).Msg("Temperature is BAD, setting fans to auto")
+			if !rateLimited {
+				rawCommandWithRetry(client, 0x30, 0x30, []byte{0x01, 0x01}, 3, 2*time.Second)
+				lastCommandTime = now
+				for zone := range zoneLastSetpoint {
+					zoneLastSetpoint[zone] = 0xff // force a fresh setpoint once we drop back to manual
+				}
+			}
+		default:
+			if rateLimited {
+				Logger.Warn().Int("max_commands_per_min", MAXCMDSPERMIN).Msg("Rate limit hit, deferring zone setpoint changes")
+				break
+			}
+			sentManualMode := false
+			for zone, zoneReadings := range groupByZone(readings) {
+				zctrl, ok := zoneControllers[zone]
+				if !ok {
+					warnTemp, maxTemp := zoneThresholds(zoneReadings, float64(WARNTEMP), float64(MAXTEMP))
+					zctrl = newZoneController(warnTemp, maxTemp)
+					zoneControllers[zone] = zctrl
+				}
+				zoneTemp := aggregate(cfg.Aggregation, zoneReadings)
+				setpoint := byte(zctrl.Update(zoneTemp, dt))
+				if setpoint == zoneLastSetpoint[zone] {
+					continue
+				}
+				if !sentManualMode {
+					rawCommandWithRetry(client, 0x30, 0x30, []byte{0x01, 0x00}, 3, 2*time.Second)
+					sentManualMode = true
+				}
+				rawCommandWithRetry(client, 0x30, 0x30, []byte{0x02, zone, setpoint}, 3, 2*time.Second)
+				fanSpeedSetpoint.WithLabelValues(fmt.Sprintf("0x%02x", zone)).Set(float64(setpoint))
+				zoneLastSetpoint[zone] = setpoint
+				Logger.Info().Str("zone", fmt.Sprintf("0x%02x", zone)).Float64("temp_c", zoneTemp).Str("fan_hex", fmt.Sprintf("0x%02x", setpoint)).Msg("Fan zone setpoint changed")
+			}
+			if sentManualMode {
+				lastCommandTime = now
+			}
+		}
 
-// executes an ipmitool
-func runIPMICommand(args []string) error {
-	cmd := exec.Command("ipmitool", append([]string{"-I", "lanplus", "-H", IPMIHOST, "-U", IPMIUSER, "-P", IPMIPW}, args...)...)
-	var out bytes.Buffer
-	cmd.Stdout = &out
-	cmd.Stderr = &out
-	err := cmd.Run()
-	if err != nil {
-		if strings.Contains(out.String(), "rsp=0xcc") && strings.Contains(out.String(), "Invalid data field in request") {
-			log.Printf("IPMI command %v executed with a minor error (rsp=0xcc), continuing. Trust me or not IDC", args)
-			return nil
+		lastPollTimestamp.Set(float64(time.Now().Unix()))
+		pulse.beat()
+		if sleepOrDone(ctx, time.Duration(POLLINTERVAL)*time.Second) {
+			break
 		}
-		log.Printf("Error running IPMI command %v: %v. Output: %s", args, err, out.String())
-		return fmt.Errorf("critical error running IPMI command %v: %v", args, err)
 	}
-	log.Printf("IPMI command %v successful. Output: %s", args, out.String())
-	return nil
 }
 
-// retries an IPMI command on failure with exponential backoff.
-func runIPMICommandWithRetry(args []string, retries int, delay time.Duration) error {
+// fanSpeedByte turns one of the hex-string FANSPEEDS entries (e.g. "0x07")
+// into the raw byte ipmitool would have sent.
+func fanSpeedByte(hex string) byte {
+	var b int64
+	fmt.Sscanf(hex, "0x%x", &b)
+	return byte(b)
+}
+
+// rawCommandWithRetry retries a raw IPMI command on failure with exponential backoff.
+func rawCommandWithRetry(client IPMIClient, netfn, cmd byte, data []byte, retries int, delay time.Duration) error {
+	var err error
 	for i := 0; i < retries; i++ {
-		err := runIPMICommand(args)
+		start := time.Now()
+		_, err = client.RawCommand(netfn, cmd, data)
+		duration := time.Since(start)
+		observeCommand(start, err)
 		if err == nil {
 			return nil
 		}
-		log.Printf("Attempt %d: Error running IPMI command %v: %v", i+1, args, err)
+		Logger.Warn().Int("attempt", i+1).Str("netfn", fmt.Sprintf("0x%02x", netfn)).Str("cmd", fmt.Sprintf("0x%02x", cmd)).Dur("duration_ms", duration).Err(err).Msg("Error running raw IPMI command")
 		time.Sleep(delay)
 		delay *= 2 // Exponential backoff
 	}
-	return fmt.Errorf("failed to run IPMI command %v after %d retries", args, retries)
+	return fmt.Errorf("failed to run raw IPMI command netfn=0x%02x cmd=0x%02x after %d retries: %v", netfn, cmd, retries, err)
 }
 
-// fetches and parses the temperature from the IPMI sensor.
-func getTemperature() (int, error) {
-	cmd := exec.Command("ipmitool", "-I", "lanplus", "-H", IPMIHOST, "-U", IPMIUSER, "-P", IPMIPW, "sdr", "get", SENSOR)
-	output, err := cmd.Output()
-	if err != nil {
-		return 0, fmt.Errorf("error running IPMI temperature command: %v", err)
-	}
-	lines := strings.Split(string(output), "\n")
-	for _, line := range lines {
-		if strings.Contains(line, "Sensor Reading") {
-			parts := strings.Fields(line)
-			if len(parts) >= 4 {
-				temp, err := strconv.Atoi(parts[3])
-				if err != nil {
-					return 0, fmt.Errorf("error parsing temperature: %v", err)
-				}
-				return temp, nil
-			}
-		}
+// recoverFromPanic logs a crash and exits non-zero instead of recursively
+// re-entering main: under systemd (Restart=on-failure) that gives us a
+// clean process restart without the leaked log fd / growing stack that
+// calling main() again from inside a panic handler would accumulate.
+func recoverFromPanic() {
+	if r := recover(); r != nil {
+		Logger.Error().Interface("panic", r).Msg("Program crashed, exiting for systemd to restart")
+		os.Exit(1)
 	}
-	return 0, fmt.Errorf("temperature reading not found in output: %s", output)
 }
 
 func getEnv(key, defaultVal string) string {
@@ -163,3 +213,11 @@ func getEnvInt(key string, defaultVal int) int {
 	}
 	return defaultVal
 }
+
+func getEnvFloat(key string, defaultVal float64) float64 {
+	valueStr := getEnv(key, "")
+	if value, err := strconv.ParseFloat(valueStr, 64); err == nil {
+		return value
+	}
+	return defaultVal
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// METRICSADDR enables the embedded Prometheus endpoint when set, e.g.
+// METRICS_ADDR=:9290. Empty (the default) disables metrics entirely.
+var METRICSADDR = getEnv("METRICS_ADDR", "")
+
+var (
+	sensorTemperature = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "ipmi_sensor_temperature_celsius",
+		Help: "Most recently polled temperature reading per sensor.",
+	}, []string{"sensor"})
+
+	fanMode = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "ipmi_fan_mode",
+		Help: "1 if the fan controller is currently in this mode (auto or manual), 0 otherwise.",
+	}, []string{"mode"})
+
+	fanSpeedSetpoint = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "ipmi_fan_speed_setpoint_hex",
+		Help: "Current fan speed setpoint per fan zone, as the raw hex byte sent to the BMC.",
+	}, []string{"zone"})
+
+	commandFailures = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "ipmi_command_failures_total",
+		Help: "Total number of failed IPMI command attempts (sensor reads or raw commands), counting each failed retry attempt.",
+	})
+
+	commandDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "ipmi_command_duration_seconds",
+		Help:    "Latency of individual IPMI commands, including retried attempts.",
+		Buckets: prometheus.DefBuckets,
+	})
+
+	lastPollTimestamp = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "ipmi_last_poll_timestamp_seconds",
+		Help: "Unix timestamp of the last completed poll loop iteration.",
+	})
+)
+
+// startMetricsServer launches the Prometheus /metrics endpoint in the
+// background if METRICSADDR is configured. It is a no-op otherwise.
+func startMetricsServer() {
+	if METRICSADDR == "" {
+		return
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	go func() {
+		Logger.Info().Str("addr", METRICSADDR).Msg("Serving Prometheus metrics on /metrics")
+		if err := http.ListenAndServe(METRICSADDR, mux); err != nil {
+			Logger.Warn().Err(err).Msg("Metrics server stopped")
+		}
+	}()
+}
+
+// setFanMode records which fan mode is currently active, zeroing out the
+// other so ipmi_fan_mode always reflects exactly one selected label.
+func setFanMode(mode string) {
+	fanMode.WithLabelValues("auto").Set(0)
+	fanMode.WithLabelValues("manual").Set(0)
+	fanMode.WithLabelValues(mode).Set(1)
+}
+
+// observeCommand records the duration of an IPMI command and, on failure,
+// increments the failure counter.
+func observeCommand(start time.Time, err error) {
+	commandDuration.Observe(time.Since(start).Seconds())
+	if err != nil {
+		commandFailures.Inc()
+	}
+}